@@ -0,0 +1,22 @@
+package assert
+
+import "fmt"
+
+// Assert panics if something is false. It is used for invariants that
+// should never be violated by correct code, as opposed to runtime errors
+// that callers are expected to handle (see the serviceError/RedisError
+// types instead for those).
+func Assert(something bool) {
+	if !something {
+		panic("assertion failed")
+	}
+}
+
+// AssertOrPanicf is like Assert but allows a formatted message for
+// invariants where the plain "assertion failed" text isn't informative
+// enough on its own.
+func AssertOrPanicf(something bool, format string, args ...interface{}) {
+	if !something {
+		panic(fmt.Sprintf(format, args...))
+	}
+}
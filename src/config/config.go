@@ -0,0 +1,250 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	pb "github.com/envoyproxy/go-control-plane/envoy/service/ratelimit/v3"
+	stats "github.com/lyft/gostats"
+	"gopkg.in/yaml.v2"
+)
+
+// RateLimitConfigError is raised (via panic) while loading a single
+// descriptor definition and is recovered by the caller, which treats it
+// as a config problem rather than a crash.
+type RateLimitConfigError string
+
+func (e RateLimitConfigError) Error() string {
+	return string(e)
+}
+
+// RateLimitConfigToLoad is one runtime snapshot entry: the key the value
+// was loaded from (used only for error messages) and its raw YAML
+// contents.
+type RateLimitConfigToLoad struct {
+	Name      string
+	FileBytes string
+}
+
+// ConcurrencyReleaseKey is a descriptor entry key that signals a cache
+// implementation to release a previously acquired concurrency slot
+// rather than acquire a new one. It lives here (re-exported by
+// limiter.ConcurrencyReleaseKey) rather than in the limiter package so
+// GetLimit can strip it when computing a descriptor's match key without
+// an import cycle.
+const ConcurrencyReleaseKey = "_release_concurrency"
+
+// RateLimit is the runtime representation of a single configured limit,
+// reachable by the full underscore-joined key built from the descriptor
+// path that defines it (e.g. "some_domain_key1_value1_key2_value2").
+type RateLimit struct {
+	FullKey string
+	Stats   stats.Scope
+	Limit   *pb.RateLimitResponse_RateLimit
+
+	// Algorithm selects which implementation in the limiter/redis
+	// packages enforces this limit. Defaults to FixedWindow when unset,
+	// which preserves the historical counter-per-window behavior. It is
+	// ignored for Global limits, which always count hits per fixed window.
+	Algorithm Algorithm
+
+	// Burst is the maximum number of tokens (token bucket) or the
+	// maximum queue level (leaky bucket) the limit will hold before
+	// rejecting. Defaults to RequestsPerUnit when zero.
+	Burst uint32
+
+	// ConcurrencyLimit, when non-zero, additionally caps the number of
+	// in-flight requests carrying this descriptor. It is checked
+	// alongside (not instead of) the QPS limit above. It is not checked
+	// for Global limits.
+	ConcurrencyLimit uint32
+
+	// LimitedByQPS and LimitedByConcurrency count OVER_LIMIT responses
+	// caused by each dimension, so operators can tell which one is
+	// actually being hit.
+	LimitedByQPS         stats.Counter
+	LimitedByConcurrency stats.Counter
+
+	// Global opts this limit into cross-replica aggregation: hits are
+	// counted against an in-memory, peer-synchronized snapshot instead
+	// of redis. See the peer package and redis.RateLimitCacheImpl.
+	Global bool
+}
+
+// Algorithm identifies which rate-limiting strategy a RateLimit enforces.
+type Algorithm string
+
+const (
+	FixedWindow Algorithm = "FIXED_WINDOW"
+	TokenBucket Algorithm = "TOKEN_BUCKET"
+	LeakyBucket Algorithm = "LEAKY_BUCKET"
+)
+
+// isValid reports whether a is one of the known Algorithm values.
+func (a Algorithm) isValid() bool {
+	switch a {
+	case FixedWindow, TokenBucket, LeakyBucket:
+		return true
+	default:
+		return false
+	}
+}
+
+// RateLimitConfig resolves descriptors from a ShouldRateLimit call against
+// whatever was loaded from YAML for a given domain.
+type RateLimitConfig interface {
+	GetLimit(ctx context.Context, domain string, descriptor *pb.RateLimitDescriptor) *RateLimit
+	Dump() string
+}
+
+// RateLimitConfigLoader parses a set of runtime snapshot entries into a
+// RateLimitConfig. Implementations panic with RateLimitConfigError on bad
+// input; service.reloadConfig recovers it.
+type RateLimitConfigLoader interface {
+	Load(configs []RateLimitConfigToLoad, statsScope stats.Scope) RateLimitConfig
+}
+
+type yamlRateLimit struct {
+	Unit             string `yaml:"unit"`
+	RequestsPerUnit  uint32 `yaml:"requests_per_unit"`
+	Algorithm        string `yaml:"algorithm"`
+	Burst            uint32 `yaml:"burst"`
+	ConcurrencyLimit uint32 `yaml:"concurrency_limit"`
+	Global           bool   `yaml:"global"`
+}
+
+type yamlDescriptor struct {
+	Key         string           `yaml:"key"`
+	Value       string           `yaml:"value"`
+	RateLimit   *yamlRateLimit   `yaml:"rate_limit"`
+	Descriptors []yamlDescriptor `yaml:"descriptors"`
+}
+
+type yamlRoot struct {
+	Domain      string           `yaml:"domain"`
+	Descriptors []yamlDescriptor `yaml:"descriptors"`
+}
+
+type rateLimitConfigImpl struct {
+	// domain -> full key -> limit
+	domains map[string]map[string]*RateLimit
+}
+
+func (c *rateLimitConfigImpl) Dump() string {
+	b := strings.Builder{}
+	for domain, limits := range c.domains {
+		for key := range limits {
+			fmt.Fprintf(&b, "%s: %s\n", domain, key)
+		}
+	}
+	return b.String()
+}
+
+func (c *rateLimitConfigImpl) GetLimit(
+	ctx context.Context, domain string, descriptor *pb.RateLimitDescriptor) *RateLimit {
+
+	limits, ok := c.domains[domain]
+	if !ok {
+		return nil
+	}
+
+	parts := make([]string, 0, len(descriptor.GetEntries())*2)
+	for _, entry := range descriptor.GetEntries() {
+		// The concurrency-release marker entry is a cache-layer signal,
+		// not part of the descriptor path a limit was configured under,
+		// so it must not affect which RateLimit a release resolves to.
+		if entry.GetKey() == ConcurrencyReleaseKey {
+			continue
+		}
+		parts = append(parts, entry.GetKey(), entry.GetValue())
+	}
+
+	return limits[strings.Join(parts, "_")]
+}
+
+type rateLimitConfigLoaderImpl struct{}
+
+// NewRateLimitConfigLoaderImpl returns the production RateLimitConfigLoader
+// that parses the runtime snapshot's YAML config files.
+func NewRateLimitConfigLoaderImpl() RateLimitConfigLoader {
+	return &rateLimitConfigLoaderImpl{}
+}
+
+func (l *rateLimitConfigLoaderImpl) Load(
+	configs []RateLimitConfigToLoad, statsScope stats.Scope) RateLimitConfig {
+
+	ret := &rateLimitConfigImpl{domains: map[string]map[string]*RateLimit{}}
+	for _, config := range configs {
+		root := yamlRoot{}
+		if err := yaml.Unmarshal([]byte(config.FileBytes), &root); err != nil {
+			panic(RateLimitConfigError(
+				fmt.Sprintf("%s: error loading config file: %s", config.Name, err.Error())))
+		}
+
+		if root.Domain == "" {
+			continue
+		}
+
+		if _, ok := ret.domains[root.Domain]; ok {
+			panic(RateLimitConfigError(
+				fmt.Sprintf("%s: duplicate domain '%s'", config.Name, root.Domain)))
+		}
+
+		domainLimits := map[string]*RateLimit{}
+		loadDescriptors(config.Name, root.Domain, "", root.Descriptors, domainLimits, statsScope)
+		ret.domains[root.Domain] = domainLimits
+	}
+
+	return ret
+}
+
+func loadDescriptors(
+	configName string, domain string, prefix string, descriptors []yamlDescriptor,
+	out map[string]*RateLimit, statsScope stats.Scope) {
+
+	for _, d := range descriptors {
+		if d.Key == "" {
+			panic(RateLimitConfigError(fmt.Sprintf("%s: descriptor has no key", configName)))
+		}
+
+		fullKey := prefix + d.Key
+		if d.Value != "" {
+			fullKey += "_" + d.Value
+		}
+
+		if d.RateLimit != nil {
+			algorithm := Algorithm(d.RateLimit.Algorithm)
+			if algorithm == "" {
+				algorithm = FixedWindow
+			}
+			if !algorithm.isValid() {
+				panic(RateLimitConfigError(fmt.Sprintf(
+					"%s: %s: invalid algorithm %q", configName, fullKey, d.RateLimit.Algorithm)))
+			}
+
+			burst := d.RateLimit.Burst
+			if burst == 0 {
+				burst = d.RateLimit.RequestsPerUnit
+			}
+
+			limitStats := statsScope.Scope(domain).Scope(fullKey)
+			out[fullKey] = &RateLimit{
+				FullKey:              domain + "_" + fullKey,
+				Stats:                limitStats,
+				Algorithm:            algorithm,
+				Burst:                burst,
+				ConcurrencyLimit:     d.RateLimit.ConcurrencyLimit,
+				Global:               d.RateLimit.Global,
+				LimitedByQPS:         limitStats.NewCounter("limited_by_qps"),
+				LimitedByConcurrency: limitStats.NewCounter("limited_by_concurrency"),
+				Limit: &pb.RateLimitResponse_RateLimit{
+					RequestsPerUnit: d.RateLimit.RequestsPerUnit,
+					Unit:            pb.RateLimitResponse_RateLimit_Unit(pb.RateLimitResponse_RateLimit_Unit_value[d.RateLimit.Unit]),
+				},
+			}
+		}
+
+		loadDescriptors(configName, domain, fullKey+"_", d.Descriptors, out, statsScope)
+	}
+}
@@ -0,0 +1,32 @@
+package limiter
+
+import (
+	"context"
+
+	pb "github.com/envoyproxy/go-control-plane/envoy/service/ratelimit/v3"
+	"github.com/replicon/ratelimit/src/config"
+)
+
+// RateLimitCache is the interface satisfied by the storage-backed rate
+// limit implementations (currently only the redis package). DoLimit
+// checks (and updates) the limits returned by
+// config.RateLimitConfig.GetLimit for every descriptor in the request and
+// returns one status per descriptor, in the same order as limits.
+//
+// limits[i] is nil when no limit is configured for request.Descriptors[i];
+// implementations must return an OK status for those without touching
+// storage.
+type RateLimitCache interface {
+	DoLimit(ctx context.Context, request *pb.RateLimitRequest, limits []*config.RateLimit) []*pb.RateLimitResponse_DescriptorStatus
+}
+
+// ConcurrencyReleaseKey is a descriptor entry key that signals a cache
+// implementation to release a previously acquired concurrency slot rather
+// than acquire a new one. Callers that finished the work a descriptor was
+// guarding piggy-back it onto a descriptor sent through the normal
+// ShouldRateLimit RPC, keyed on the same entries as the original request.
+//
+// The value lives in config.ConcurrencyReleaseKey (re-exported here)
+// because config.RateLimitConfig.GetLimit has to strip it when matching
+// a descriptor, and config can't import this package back.
+const ConcurrencyReleaseKey = config.ConcurrencyReleaseKey
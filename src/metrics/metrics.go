@@ -0,0 +1,36 @@
+package metrics
+
+import "github.com/prometheus/client_golang/prometheus"
+
+var (
+	// RateLimitErrors counts failures by the stage that produced them
+	// (e.g. "redis", "service", "config_reload").
+	RateLimitErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "ratelimit_errors_total",
+		Help: "Total number of rate limit errors by type",
+	}, []string{"type"})
+
+	// RateLimitRequestSummary tracks the latency distribution of
+	// ShouldRateLimit calls, in seconds.
+	RateLimitRequestSummary = prometheus.NewSummary(prometheus.SummaryOpts{
+		Name: "ratelimit_request_duration_seconds",
+		Help: "Duration of ShouldRateLimit calls",
+	})
+
+	// LimitedRequests and ShadowRequests count descriptor statuses that
+	// came back OVER_LIMIT, split out by whether shadow mode suppressed
+	// the actual rejection.
+	LimitedRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "ratelimit_over_limit_total",
+		Help: "Total number of requests rejected for exceeding a rate limit",
+	}, []string{"descriptor_key", "descriptor_value", "limit", "unit"})
+
+	ShadowRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "ratelimit_shadow_over_limit_total",
+		Help: "Total number of requests that would have been rejected if not for shadow mode",
+	}, []string{"descriptor_key", "descriptor_value", "limit", "unit"})
+)
+
+func init() {
+	prometheus.MustRegister(RateLimitErrors, RateLimitRequestSummary, LimitedRequests, ShadowRequests)
+}
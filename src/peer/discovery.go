@@ -0,0 +1,64 @@
+package peer
+
+import (
+	"fmt"
+	"net"
+)
+
+// Peer identifies a replica that participates in global limit
+// aggregation, addressable over gRPC.
+type Peer struct {
+	Address string
+}
+
+// Discovery resolves the current set of peers a replica should forward
+// aggregated hit counts to. Implementations are expected to be cheap to
+// call repeatedly, since Forwarder re-resolves on every broadcast tick.
+type Discovery interface {
+	Peers() ([]Peer, error)
+}
+
+type staticDiscovery struct {
+	peers []Peer
+}
+
+// NewStaticDiscovery returns a Discovery backed by a fixed address list,
+// for deployments that don't have (or don't need) a service registry.
+func NewStaticDiscovery(addresses []string) Discovery {
+	peers := make([]Peer, len(addresses))
+	for i, addr := range addresses {
+		peers[i] = Peer{Address: addr}
+	}
+	return &staticDiscovery{peers: peers}
+}
+
+func (d *staticDiscovery) Peers() ([]Peer, error) {
+	return d.peers, nil
+}
+
+type dnsSRVDiscovery struct {
+	service string
+	proto   string
+	name    string
+}
+
+// NewDNSSRVDiscovery returns a Discovery that re-resolves the peer set
+// from a DNS SRV record on every call, for deployments that register
+// replicas via a headless service or service mesh rather than a fixed
+// address list.
+func NewDNSSRVDiscovery(service, proto, name string) Discovery {
+	return &dnsSRVDiscovery{service: service, proto: proto, name: name}
+}
+
+func (d *dnsSRVDiscovery) Peers() ([]Peer, error) {
+	_, records, err := net.LookupSRV(d.service, d.proto, d.name)
+	if err != nil {
+		return nil, fmt.Errorf("peer: dns srv lookup for %s failed: %w", d.name, err)
+	}
+
+	peers := make([]Peer, len(records))
+	for i, record := range records {
+		peers[i] = Peer{Address: fmt.Sprintf("%s:%d", record.Target, record.Port)}
+	}
+	return peers, nil
+}
@@ -0,0 +1,77 @@
+package peer
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	logger "github.com/sirupsen/logrus"
+)
+
+// Forwarder periodically drains locally observed hits and broadcasts
+// them to every peer Discovery returns, on its own goroutine. It trades
+// a small amount of cross-replica slack for avoiding a redis round trip
+// on every request for globally-aggregated limits.
+type Forwarder struct {
+	replicaName string
+	discovery   Discovery
+	local       LocalCounts
+	client      Client
+	interval    time.Duration
+
+	stopOnce sync.Once
+	stopCh   chan struct{}
+}
+
+// NewForwarder constructs a Forwarder. Call Start (typically `go
+// forwarder.Start()`) to begin broadcasting; call Stop to end the loop.
+func NewForwarder(replicaName string, discovery Discovery, local LocalCounts, client Client, interval time.Duration) *Forwarder {
+	return &Forwarder{
+		replicaName: replicaName,
+		discovery:   discovery,
+		local:       local,
+		client:      client,
+		interval:    interval,
+		stopCh:      make(chan struct{}),
+	}
+}
+
+func (f *Forwarder) Start() {
+	ticker := time.NewTicker(f.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-f.stopCh:
+			return
+		case <-ticker.C:
+			f.broadcastOnce()
+		}
+	}
+}
+
+func (f *Forwarder) Stop() {
+	f.stopOnce.Do(func() { close(f.stopCh) })
+}
+
+func (f *Forwarder) broadcastOnce() {
+	deltas := f.local.DrainLocalDeltas()
+	if len(deltas) == 0 {
+		return
+	}
+
+	peers, err := f.discovery.Peers()
+	if err != nil {
+		logger.Warnf("peer: failed to resolve peers, dropping %d deltas: %s", len(deltas), err.Error())
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), f.interval)
+	defer cancel()
+
+	for _, p := range peers {
+		if err := f.client.UpdatePeerLimits(ctx, p, f.replicaName, deltas); err != nil {
+			logger.Warnf("peer: failed to forward deltas to %s: %s", p.Address, err.Error())
+		}
+	}
+}
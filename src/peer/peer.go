@@ -0,0 +1,50 @@
+// Package peer defines the transport-agnostic pieces of cross-replica
+// aggregation for rate limits configured with `global: true`: the
+// LimitDelta wire shape, the Client/Sink/Discovery/LocalCounts
+// interfaces, and the Forwarder goroutine that drains local hits on an
+// interval and sends them through a Client.
+//
+// The wire contract is PeerService.UpdatePeerLimits, defined in
+// proto/peer/v1/peer.proto. This package does not yet include generated
+// protoc-gen-go-grpc stubs for that service, a concrete Client that
+// dials a Peer over gRPC, or a server-side handler that calls
+// Sink.ApplyPeerDelta for incoming requests - those are follow-up work.
+// Until a concrete Client is wired in (see
+// redis.NewRateLimitCacheImpl's peerClient argument), `global: true`
+// limits only aggregate within the local process.
+package peer
+
+import "context"
+
+// LimitDelta is the number of hits a replica observed locally for a
+// config.RateLimit's FullKey since its last broadcast, scoped to the
+// fixed window (Bucket) those hits were observed in, so a receiving
+// replica can tell a delta for the current window apart from one left
+// over from a window that has already rolled over.
+type LimitDelta struct {
+	FullKey string
+	Bucket  int64
+	Hits    int64
+}
+
+// Client is the sending side of the PeerService contract. It is an
+// interface so Forwarder can be exercised without a real gRPC connection;
+// no concrete, gRPC-backed implementation exists in this package yet.
+type Client interface {
+	UpdatePeerLimits(ctx context.Context, to Peer, sourceReplica string, deltas []LimitDelta) error
+}
+
+// LocalCounts is implemented by the cache backend so Forwarder can drain
+// the hit counts accumulated locally since the last broadcast without
+// depending on the backend's storage details.
+type LocalCounts interface {
+	DrainLocalDeltas() []LimitDelta
+}
+
+// Sink receives deltas broadcast by other replicas and folds them into
+// whatever local aggregated snapshot DoLimit reads for global limits. A
+// gRPC PeerService handler, once one is wired up, would call
+// ApplyPeerDelta for every UpdatePeerLimits request it accepts.
+type Sink interface {
+	ApplyPeerDelta(fromReplica string, deltas []LimitDelta)
+}
@@ -0,0 +1,81 @@
+package redis
+
+import (
+	"testing"
+
+	pb "github.com/envoyproxy/go-control-plane/envoy/service/ratelimit/v3"
+	"github.com/replicon/ratelimit/src/config"
+)
+
+func scriptedEvalClient(result []interface{}) *fakeClient {
+	return &fakeClient{
+		onEval: func(script string, response interface{}, args ...interface{}) error {
+			*(response.(*[]interface{})) = result
+			return nil
+		},
+	}
+}
+
+func TestDoTokenBucket_AllowedWithinBurst(t *testing.T) {
+	cache := &RateLimitCacheImpl{client: scriptedEvalClient([]interface{}{int64(1), "4", "0"})}
+	rl := &config.RateLimit{
+		FullKey: "k", Burst: 5,
+		Limit: &pb.RateLimitResponse_RateLimit{RequestsPerUnit: 5, Unit: pb.RateLimitResponse_RateLimit_SECOND},
+	}
+
+	status := cache.doTokenBucket(rl, 1)
+	if status.Code != pb.RateLimitResponse_OK {
+		t.Fatalf("expected OK, got %v", status.Code)
+	}
+	if status.LimitRemaining != 4 {
+		t.Fatalf("expected 4 remaining, got %d", status.LimitRemaining)
+	}
+}
+
+func TestDoTokenBucket_RejectedWhenDrained(t *testing.T) {
+	cache := &RateLimitCacheImpl{client: scriptedEvalClient([]interface{}{int64(0), "0", "1"})}
+	rl := &config.RateLimit{
+		FullKey: "k", Burst: 5,
+		Limit: &pb.RateLimitResponse_RateLimit{RequestsPerUnit: 5, Unit: pb.RateLimitResponse_RateLimit_SECOND},
+	}
+
+	status := cache.doTokenBucket(rl, 1)
+	if status.Code != pb.RateLimitResponse_OVER_LIMIT {
+		t.Fatalf("expected OVER_LIMIT, got %v", status.Code)
+	}
+	if status.DurationUntilReset == nil {
+		t.Fatalf("expected a non-nil DurationUntilReset when rejected")
+	}
+}
+
+func TestDoLeakyBucket_AllowedBelowCapacity(t *testing.T) {
+	cache := &RateLimitCacheImpl{client: scriptedEvalClient([]interface{}{int64(1), "3"})}
+	rl := &config.RateLimit{
+		FullKey: "k", Burst: 5,
+		Limit: &pb.RateLimitResponse_RateLimit{RequestsPerUnit: 5, Unit: pb.RateLimitResponse_RateLimit_SECOND},
+	}
+
+	status := cache.doLeakyBucket(rl, 1)
+	if status.Code != pb.RateLimitResponse_OK {
+		t.Fatalf("expected OK, got %v", status.Code)
+	}
+	if status.LimitRemaining != 2 {
+		t.Fatalf("expected 2 remaining, got %d", status.LimitRemaining)
+	}
+}
+
+func TestDoLeakyBucket_RejectedAtCapacity(t *testing.T) {
+	cache := &RateLimitCacheImpl{client: scriptedEvalClient([]interface{}{int64(0), "5"})}
+	rl := &config.RateLimit{
+		FullKey: "k", Burst: 5,
+		Limit: &pb.RateLimitResponse_RateLimit{RequestsPerUnit: 5, Unit: pb.RateLimitResponse_RateLimit_SECOND},
+	}
+
+	status := cache.doLeakyBucket(rl, 1)
+	if status.Code != pb.RateLimitResponse_OVER_LIMIT {
+		t.Fatalf("expected OVER_LIMIT, got %v", status.Code)
+	}
+	if status.LimitRemaining != 0 {
+		t.Fatalf("expected 0 remaining, got %d", status.LimitRemaining)
+	}
+}
@@ -0,0 +1,87 @@
+package redis
+
+import (
+	pb "github.com/envoyproxy/go-control-plane/envoy/service/ratelimit/v3"
+	"github.com/replicon/ratelimit/src/config"
+	"github.com/replicon/ratelimit/src/limiter"
+)
+
+// concurrencyKeyTTLSeconds bounds how long a slot can be held if a caller
+// crashes before releasing it, so a leaked acquire doesn't wedge a
+// descriptor shut forever.
+const concurrencyKeyTTLSeconds = 3600
+
+// concurrencyAcquireScript increments the in-flight counter and backs the
+// increment out again if it pushed the count over the limit, so a
+// rejected request never holds a slot.
+const concurrencyAcquireScript = `
+local current = redis.call('INCR', KEYS[1])
+if tonumber(current) > tonumber(ARGV[1]) then
+	redis.call('DECR', KEYS[1])
+	return {0, current - 1}
+end
+redis.call('EXPIRE', KEYS[1], ARGV[2])
+return {1, current}
+`
+
+// concurrencyReleaseScript decrements the in-flight counter, clamping at
+// zero so a duplicate or late release can't drive it negative.
+const concurrencyReleaseScript = `
+local current = redis.call('DECR', KEYS[1])
+if tonumber(current) < 0 then
+	redis.call('SET', KEYS[1], 0)
+	current = 0
+end
+return current
+`
+
+func isConcurrencyRelease(descriptor *pb.RateLimitDescriptor) bool {
+	for _, entry := range descriptor.GetEntries() {
+		if entry.GetKey() == limiter.ConcurrencyReleaseKey {
+			return true
+		}
+	}
+	return false
+}
+
+func (this *RateLimitCacheImpl) concurrencyKey(rl *config.RateLimit) string {
+	return rl.FullKey + "_concurrency"
+}
+
+func (this *RateLimitCacheImpl) releaseConcurrency(rl *config.RateLimit) *pb.RateLimitResponse_DescriptorStatus {
+	this.decrementConcurrency(rl)
+	return &pb.RateLimitResponse_DescriptorStatus{Code: pb.RateLimitResponse_OK}
+}
+
+// decrementConcurrency releases a previously acquired slot without
+// producing a descriptor status, for use when a request that already
+// acquired a slot ends up OVER_LIMIT for some other reason (e.g. QPS)
+// and never gets a chance to run real work the slot was meant to guard.
+func (this *RateLimitCacheImpl) decrementConcurrency(rl *config.RateLimit) {
+	var result interface{}
+	if err := this.client.DoCmd(&result, "EVAL", concurrencyReleaseScript, 1, this.concurrencyKey(rl)); err != nil {
+		panic(NewRedisError(err))
+	}
+}
+
+// acquireConcurrency returns (status, acquired). acquired is false when
+// the slot was rejected, so DoLimit can skip the QPS check's stats for a
+// descriptor that never got a chance to run it.
+func (this *RateLimitCacheImpl) acquireConcurrency(rl *config.RateLimit) (*pb.RateLimitResponse_DescriptorStatus, bool) {
+	var result []interface{}
+	err := this.client.DoCmd(&result, "EVAL", concurrencyAcquireScript, 1, this.concurrencyKey(rl),
+		rl.ConcurrencyLimit, concurrencyKeyTTLSeconds)
+	if err != nil {
+		panic(NewRedisError(err))
+	}
+
+	if toInt64(result[0]) != 1 {
+		rl.LimitedByConcurrency.Inc()
+		return &pb.RateLimitResponse_DescriptorStatus{
+			Code:         pb.RateLimitResponse_OVER_LIMIT,
+			CurrentLimit: rl.Limit,
+		}, false
+	}
+
+	return nil, true
+}
@@ -0,0 +1,127 @@
+package redis
+
+import (
+	"context"
+	"testing"
+
+	pb "github.com/envoyproxy/go-control-plane/envoy/service/ratelimit/v3"
+	"github.com/replicon/ratelimit/src/config"
+	"github.com/replicon/ratelimit/src/limiter"
+)
+
+// fakeClient is a Client that answers EVAL calls by script text, so tests
+// can exercise the Go-level dispatch/status logic without a real redis
+// running the Lua.
+type fakeClient struct {
+	onEval func(script string, response interface{}, args ...interface{}) error
+}
+
+func (f *fakeClient) DoCmd(response interface{}, cmd string, args ...interface{}) error {
+	script := args[0].(string)
+	return f.onEval(script, response, args[2:]...)
+}
+
+func (f *fakeClient) PipeAppend(cmd string, args ...interface{}) {}
+func (f *fakeClient) PipeDo() ([]interface{}, error)             { return nil, nil }
+func (f *fakeClient) Close() error                               { return nil }
+
+type noopCounter struct{}
+
+func (noopCounter) Add(uint64)     {}
+func (noopCounter) Inc()           {}
+func (noopCounter) Set(uint64)     {}
+func (noopCounter) String() string { return "" }
+func (noopCounter) Value() uint64  { return 0 }
+
+func descriptorWithEntries(entries ...*pb.RateLimitDescriptor_Entry) *pb.RateLimitDescriptor {
+	return &pb.RateLimitDescriptor{Entries: entries}
+}
+
+func TestAcquireConcurrency_Allowed(t *testing.T) {
+	client := &fakeClient{
+		onEval: func(script string, response interface{}, args ...interface{}) error {
+			*(response.(*[]interface{})) = []interface{}{int64(1), int64(3)}
+			return nil
+		},
+	}
+	cache := &RateLimitCacheImpl{client: client}
+	rl := &config.RateLimit{FullKey: "k", ConcurrencyLimit: 5, LimitedByConcurrency: noopCounter{}}
+
+	_, acquired := cache.acquireConcurrency(rl)
+	if !acquired {
+		t.Fatalf("expected slot to be acquired")
+	}
+}
+
+func TestAcquireConcurrency_Rejected(t *testing.T) {
+	client := &fakeClient{
+		onEval: func(script string, response interface{}, args ...interface{}) error {
+			*(response.(*[]interface{})) = []interface{}{int64(0), int64(5)}
+			return nil
+		},
+	}
+	cache := &RateLimitCacheImpl{client: client}
+	rl := &config.RateLimit{FullKey: "k", ConcurrencyLimit: 5, LimitedByConcurrency: noopCounter{}}
+
+	status, acquired := cache.acquireConcurrency(rl)
+	if acquired {
+		t.Fatalf("expected slot to be rejected")
+	}
+	if status.Code != pb.RateLimitResponse_OVER_LIMIT {
+		t.Fatalf("expected OVER_LIMIT, got %v", status.Code)
+	}
+}
+
+func TestDoLimit_QPSOverLimitReleasesAcquiredConcurrencySlot(t *testing.T) {
+	released := false
+	client := &fakeClient{
+		onEval: func(script string, response interface{}, args ...interface{}) error {
+			switch script {
+			case concurrencyAcquireScript:
+				*(response.(*[]interface{})) = []interface{}{int64(1), int64(1)}
+			case fixedWindowScript:
+				*(response.(*[]interface{})) = []interface{}{int64(11), int64(1)}
+			case concurrencyReleaseScript:
+				released = true
+			}
+			return nil
+		},
+	}
+
+	rl := &config.RateLimit{
+		FullKey:              "test_key",
+		ConcurrencyLimit:     5,
+		Limit:                &pb.RateLimitResponse_RateLimit{RequestsPerUnit: 10, Unit: pb.RateLimitResponse_RateLimit_SECOND},
+		LimitedByQPS:         noopCounter{},
+		LimitedByConcurrency: noopCounter{},
+	}
+
+	cache := &RateLimitCacheImpl{client: client, global: newGlobalState()}
+	request := &pb.RateLimitRequest{
+		Domain:      "test",
+		Descriptors: []*pb.RateLimitDescriptor{descriptorWithEntries(&pb.RateLimitDescriptor_Entry{Key: "k", Value: "v"})},
+	}
+
+	statuses := cache.DoLimit(context.Background(), request, []*config.RateLimit{rl})
+	if statuses[0].Code != pb.RateLimitResponse_OVER_LIMIT {
+		t.Fatalf("expected OVER_LIMIT, got %v", statuses[0].Code)
+	}
+	if !released {
+		t.Fatalf("expected the acquired concurrency slot to be released when QPS rejects the request")
+	}
+}
+
+func TestIsConcurrencyRelease(t *testing.T) {
+	release := descriptorWithEntries(
+		&pb.RateLimitDescriptor_Entry{Key: "k", Value: "v"},
+		&pb.RateLimitDescriptor_Entry{Key: limiter.ConcurrencyReleaseKey, Value: "true"},
+	)
+	if !isConcurrencyRelease(release) {
+		t.Fatalf("expected descriptor carrying the release marker to be detected")
+	}
+
+	acquire := descriptorWithEntries(&pb.RateLimitDescriptor_Entry{Key: "k", Value: "v"})
+	if isConcurrencyRelease(acquire) {
+		t.Fatalf("expected descriptor without the release marker to not be detected as a release")
+	}
+}
@@ -0,0 +1,146 @@
+package redis
+
+import (
+	"context"
+	"time"
+
+	pb "github.com/envoyproxy/go-control-plane/envoy/service/ratelimit/v3"
+	"github.com/replicon/ratelimit/src/config"
+	"github.com/replicon/ratelimit/src/limiter"
+	"github.com/replicon/ratelimit/src/peer"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+// fixedWindowScript increments the counter for the current window and
+// returns its new value alongside the window's remaining TTL in seconds,
+// so a single round trip is enough to both update and check the limit.
+const fixedWindowScript = `
+local current = redis.call('INCRBY', KEYS[1], ARGV[1])
+if tonumber(current) == tonumber(ARGV[1]) then
+	redis.call('EXPIRE', KEYS[1], ARGV[2])
+end
+local ttl = redis.call('TTL', KEYS[1])
+return {current, ttl}
+`
+
+// RateLimitCacheImpl is the production limiter.RateLimitCache. It
+// dispatches each descriptor to the storage scheme selected by the
+// matched config.RateLimit's Algorithm, defaulting to the historical
+// fixed window counter when a limit predates the Algorithm field. Limits
+// with Global set are counted in memory by doGlobal instead.
+type RateLimitCacheImpl struct {
+	client Client
+	global *globalState
+}
+
+// NewRateLimitCacheImpl returns a RateLimitCacheImpl backed by client.
+// When peerDiscovery and peerClient are both non-nil, limits configured
+// with `global: true` additionally have their hits broadcast to peers
+// every broadcastInterval; with either nil, global limits still work but
+// only aggregate within this one replica.
+func NewRateLimitCacheImpl(
+	client Client,
+	peerDiscovery peer.Discovery, peerClient peer.Client, replicaName string, broadcastInterval time.Duration) limiter.RateLimitCache {
+
+	impl := &RateLimitCacheImpl{
+		client: client,
+		global: newGlobalState(),
+	}
+
+	if peerDiscovery != nil && peerClient != nil {
+		forwarder := peer.NewForwarder(replicaName, peerDiscovery, impl.global, peerClient, broadcastInterval)
+		go forwarder.Start()
+	}
+
+	return impl
+}
+
+func (this *RateLimitCacheImpl) DoLimit(
+	ctx context.Context, request *pb.RateLimitRequest,
+	limits []*config.RateLimit) []*pb.RateLimitResponse_DescriptorStatus {
+
+	responses := make([]*pb.RateLimitResponse_DescriptorStatus, len(limits))
+	for i, rl := range limits {
+		if rl == nil {
+			responses[i] = &pb.RateLimitResponse_DescriptorStatus{Code: pb.RateLimitResponse_OK}
+			continue
+		}
+
+		descriptor := request.Descriptors[i]
+
+		if rl.Global {
+			hitsAddend := descriptor.GetHitsAddend()
+			if hitsAddend == 0 {
+				hitsAddend = 1
+			}
+			responses[i] = this.doGlobal(rl, hitsAddend)
+			continue
+		}
+
+		if rl.ConcurrencyLimit > 0 && isConcurrencyRelease(descriptor) {
+			responses[i] = this.releaseConcurrency(rl)
+			continue
+		}
+
+		hitsAddend := descriptor.GetHitsAddend()
+		if hitsAddend == 0 {
+			hitsAddend = 1
+		}
+
+		if rl.ConcurrencyLimit > 0 {
+			if status, acquired := this.acquireConcurrency(rl); !acquired {
+				responses[i] = status
+				continue
+			}
+		}
+
+		switch rl.Algorithm {
+		case config.TokenBucket:
+			responses[i] = this.doTokenBucket(rl, hitsAddend)
+		case config.LeakyBucket:
+			responses[i] = this.doLeakyBucket(rl, hitsAddend)
+		default:
+			responses[i] = this.doFixedWindow(rl, hitsAddend)
+		}
+
+		if responses[i].Code == pb.RateLimitResponse_OVER_LIMIT {
+			rl.LimitedByQPS.Inc()
+
+			// A slot was already acquired above; since this request is
+			// being rejected on the QPS dimension it will never report
+			// completion, so release it here instead of holding it open
+			// until concurrencyKeyTTLSeconds.
+			if rl.ConcurrencyLimit > 0 {
+				this.decrementConcurrency(rl)
+			}
+		}
+	}
+
+	return responses
+}
+
+func (this *RateLimitCacheImpl) doFixedWindow(
+	rl *config.RateLimit, hitsAddend uint32) *pb.RateLimitResponse_DescriptorStatus {
+
+	unitSeconds := unitToSeconds(rl.Limit.Unit)
+	var result []interface{}
+	err := this.client.DoCmd(&result, "EVAL", fixedWindowScript, 1, rl.FullKey, hitsAddend, unitSeconds)
+	if err != nil {
+		panic(NewRedisError(err))
+	}
+
+	current := toInt64(result[0])
+	ttl := toInt64(result[1])
+
+	code := pb.RateLimitResponse_OK
+	if uint32(current) > rl.Limit.RequestsPerUnit {
+		code = pb.RateLimitResponse_OVER_LIMIT
+	}
+
+	return &pb.RateLimitResponse_DescriptorStatus{
+		Code:               code,
+		CurrentLimit:       rl.Limit,
+		LimitRemaining:     remaining(rl.Limit.RequestsPerUnit, uint32(current)),
+		DurationUntilReset: durationpb.New(time.Duration(ttl) * time.Second),
+	}
+}
@@ -0,0 +1,165 @@
+package redis
+
+import (
+	"sync"
+	"time"
+
+	pb "github.com/envoyproxy/go-control-plane/envoy/service/ratelimit/v3"
+	"github.com/replicon/ratelimit/src/config"
+	"github.com/replicon/ratelimit/src/peer"
+)
+
+// bucketedCount is a hit count scoped to a single fixed window, where
+// bucket is unix time divided by the limit's unit in seconds. Keeping
+// the bucket alongside the count lets callers tell a stale count (from a
+// window that has since rolled over) apart from a live one, instead of
+// the count growing forever.
+type bucketedCount struct {
+	bucket int64
+	hits   int64
+}
+
+// globalState is the local-process side of "global" limit aggregation:
+// every request for a global limit increments an in-memory counter and
+// is checked against the last snapshot broadcast by peers, rather than
+// talking to redis. A peer.Forwarder periodically drains localHits (see
+// DrainLocalDeltas) and broadcasts it; deltas received from peers are
+// folded into snapshot via ApplyPeerDelta. Each map holds one bucket's
+// count per key, which may be an older bucket than the current one (a
+// rolled-over key's hits are kept in snapshot under their own bucket);
+// recordHit only counts a snapshot entry toward the limit when its
+// bucket is the current one.
+type globalState struct {
+	mu        sync.Mutex
+	localHits map[string]*bucketedCount
+	snapshot  map[string]*bucketedCount
+}
+
+func newGlobalState() *globalState {
+	return &globalState{
+		localHits: map[string]*bucketedCount{},
+		snapshot:  map[string]*bucketedCount{},
+	}
+}
+
+// currentBucket returns the fixed window a hit observed right now falls
+// into for a limit with the given unit length.
+func currentBucket(unitSeconds int64) int64 {
+	if unitSeconds <= 0 {
+		unitSeconds = 1
+	}
+	return time.Now().Unix() / unitSeconds
+}
+
+// DrainLocalDeltas implements peer.LocalCounts. It folds each key's
+// pending local hits into snapshot (so this replica's own count keeps
+// counting toward its own view of the total) and zeroes the pending
+// count, but leaves the bucket in place so hits recorded later in the
+// same window keep accumulating on top of what was just broadcast.
+func (g *globalState) DrainLocalDeltas() []peer.LimitDelta {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	deltas := make([]peer.LimitDelta, 0, len(g.localHits))
+	for key, local := range g.localHits {
+		if local.hits == 0 {
+			continue
+		}
+
+		deltas = append(deltas, peer.LimitDelta{FullKey: key, Bucket: local.bucket, Hits: local.hits})
+
+		snap := g.snapshot[key]
+		if snap == nil || snap.bucket != local.bucket {
+			snap = &bucketedCount{bucket: local.bucket}
+			g.snapshot[key] = snap
+		}
+		snap.hits += local.hits
+		local.hits = 0
+	}
+	return deltas
+}
+
+// ApplyPeerDelta implements peer.Sink. A delta for a bucket older than
+// what's already stored is dropped as stale; a delta for a newer bucket
+// starts the snapshot fresh, so a key that's gone quiet doesn't keep
+// counting a window that has already rolled over.
+func (g *globalState) ApplyPeerDelta(fromReplica string, deltas []peer.LimitDelta) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	for _, d := range deltas {
+		snap := g.snapshot[d.FullKey]
+		if snap != nil && snap.bucket > d.Bucket {
+			continue
+		}
+		if snap == nil || snap.bucket != d.Bucket {
+			snap = &bucketedCount{bucket: d.Bucket}
+			g.snapshot[d.FullKey] = snap
+		}
+		snap.hits += d.Hits
+	}
+}
+
+// recordHit accounts hits against the current window's local counter and
+// returns the total the caller should check against the limit: the last
+// known cross-replica snapshot for this window plus hits this replica
+// hasn't broadcast yet. Counts from a window other than the current one
+// are treated as zero instead of carried forward.
+func (g *globalState) recordHit(fullKey string, hits int64, unitSeconds int64) int64 {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	bucket := currentBucket(unitSeconds)
+
+	local := g.localHits[fullKey]
+	if local == nil || local.bucket != bucket {
+		if local != nil && local.hits != 0 {
+			// Roll over: fold whatever this replica accumulated for the
+			// outgoing bucket into the snapshot now, the same way
+			// DrainLocalDeltas does, instead of discarding it - the
+			// Forwarder's ticker is independent of bucket boundaries and
+			// may not have drained it yet.
+			snap := g.snapshot[fullKey]
+			if snap == nil || snap.bucket != local.bucket {
+				snap = &bucketedCount{bucket: local.bucket}
+				g.snapshot[fullKey] = snap
+			}
+			snap.hits += local.hits
+		}
+		local = &bucketedCount{bucket: bucket}
+		g.localHits[fullKey] = local
+	}
+	local.hits += hits
+
+	var snapHits int64
+	if snap := g.snapshot[fullKey]; snap != nil && snap.bucket == bucket {
+		snapHits = snap.hits
+	}
+
+	return snapHits + local.hits
+}
+
+func (this *RateLimitCacheImpl) doGlobal(
+	rl *config.RateLimit, hitsAddend uint32) *pb.RateLimitResponse_DescriptorStatus {
+
+	unitSeconds := unitToSeconds(rl.Limit.Unit)
+	total := this.global.recordHit(rl.FullKey, int64(hitsAddend), unitSeconds)
+
+	code := pb.RateLimitResponse_OK
+	if total > int64(rl.Limit.RequestsPerUnit) {
+		code = pb.RateLimitResponse_OVER_LIMIT
+	}
+
+	return &pb.RateLimitResponse_DescriptorStatus{
+		Code:           code,
+		CurrentLimit:   rl.Limit,
+		LimitRemaining: remaining(rl.Limit.RequestsPerUnit, uint32(total)),
+	}
+}
+
+// PeerSink exposes the cache's aggregated snapshot so the process wiring
+// up the PeerService gRPC handler can route incoming UpdatePeerLimits
+// calls into it.
+func (this *RateLimitCacheImpl) PeerSink() peer.Sink {
+	return this.global
+}
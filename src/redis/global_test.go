@@ -0,0 +1,97 @@
+package redis
+
+import (
+	"testing"
+
+	pb "github.com/envoyproxy/go-control-plane/envoy/service/ratelimit/v3"
+	"github.com/replicon/ratelimit/src/config"
+	"github.com/replicon/ratelimit/src/peer"
+)
+
+func TestDoGlobal_WindowResetsAfterRollover(t *testing.T) {
+	cache := &RateLimitCacheImpl{global: newGlobalState()}
+	rl := &config.RateLimit{
+		FullKey: "test_global",
+		Global:  true,
+		Limit:   &pb.RateLimitResponse_RateLimit{RequestsPerUnit: 2, Unit: pb.RateLimitResponse_RateLimit_SECOND},
+	}
+
+	if status := cache.doGlobal(rl, 1); status.Code != pb.RateLimitResponse_OK {
+		t.Fatalf("expected 1st hit in window to be OK, got %v", status.Code)
+	}
+	if status := cache.doGlobal(rl, 1); status.Code != pb.RateLimitResponse_OK {
+		t.Fatalf("expected 2nd hit in window to be OK, got %v", status.Code)
+	}
+	if status := cache.doGlobal(rl, 1); status.Code != pb.RateLimitResponse_OVER_LIMIT {
+		t.Fatalf("expected 3rd hit in window to be OVER_LIMIT, got %v", status.Code)
+	}
+
+	// Simulate the window rolling over: without bucket-scoping, the
+	// in-memory counter never resets and every later request in any
+	// future window would also see OVER_LIMIT forever.
+	cache.global.mu.Lock()
+	cache.global.localHits[rl.FullKey].bucket--
+	cache.global.mu.Unlock()
+
+	if status := cache.doGlobal(rl, 1); status.Code != pb.RateLimitResponse_OK {
+		t.Fatalf("expected hit in the next window to be OK, got %v", status.Code)
+	}
+}
+
+func TestDoGlobal_RolloverFoldsPendingHitsIntoSnapshot(t *testing.T) {
+	cache := &RateLimitCacheImpl{global: newGlobalState()}
+	rl := &config.RateLimit{
+		FullKey: "test_global",
+		Global:  true,
+		Limit:   &pb.RateLimitResponse_RateLimit{RequestsPerUnit: 100, Unit: pb.RateLimitResponse_RateLimit_SECOND},
+	}
+
+	// Accumulate local hits that the Forwarder hasn't drained yet, then
+	// force the bucket to roll over before it gets a chance to.
+	cache.doGlobal(rl, 1)
+	cache.doGlobal(rl, 1)
+	cache.doGlobal(rl, 1)
+
+	cache.global.mu.Lock()
+	cache.global.localHits[rl.FullKey].bucket--
+	cache.global.mu.Unlock()
+
+	// The next hit lands in the new bucket, but the 3 hits stranded in
+	// the outgoing bucket must have been folded into the snapshot under
+	// their own bucket rather than discarded.
+	cache.doGlobal(rl, 1)
+
+	cache.global.mu.Lock()
+	snap := cache.global.snapshot[rl.FullKey]
+	cache.global.mu.Unlock()
+
+	if snap == nil || snap.hits != 3 {
+		t.Fatalf("expected outgoing bucket's 3 pending hits to survive in the snapshot, got %+v", snap)
+	}
+}
+
+func TestApplyPeerDelta_DropsStaleBucket(t *testing.T) {
+	g := newGlobalState()
+	g.ApplyPeerDelta("replica-a", []peer.LimitDelta{{FullKey: "k", Bucket: 5, Hits: 10}})
+	g.ApplyPeerDelta("replica-a", []peer.LimitDelta{{FullKey: "k", Bucket: 4, Hits: 100}})
+
+	g.mu.Lock()
+	hits := g.snapshot["k"].hits
+	bucket := g.snapshot["k"].bucket
+	g.mu.Unlock()
+
+	if bucket != 5 || hits != 10 {
+		t.Fatalf("expected stale bucket 4 delta to be dropped, got bucket=%d hits=%d", bucket, hits)
+	}
+
+	g.ApplyPeerDelta("replica-a", []peer.LimitDelta{{FullKey: "k", Bucket: 6, Hits: 3}})
+
+	g.mu.Lock()
+	hits = g.snapshot["k"].hits
+	bucket = g.snapshot["k"].bucket
+	g.mu.Unlock()
+
+	if bucket != 6 || hits != 3 {
+		t.Fatalf("expected newer bucket to start fresh, got bucket=%d hits=%d", bucket, hits)
+	}
+}
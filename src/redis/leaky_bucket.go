@@ -0,0 +1,65 @@
+package redis
+
+import (
+	pb "github.com/envoyproxy/go-control-plane/envoy/service/ratelimit/v3"
+	"github.com/replicon/ratelimit/src/config"
+)
+
+// leakyBucketScript drains `level` by rate*elapsed/unit (floor zero),
+// then adds the incoming hits and rejects if the resulting level would
+// exceed capacity (rl.Burst, defaulting to the configured limit). As with
+// tokenBucketScript, leak and fill happen atomically server-side.
+const leakyBucketScript = `
+local now = redis.call('TIME')
+local now_ns = (tonumber(now[1]) * 1000000000) + (tonumber(now[2]) * 1000)
+local data = redis.call('HMGET', KEYS[1], 'level', 'last_leak_ns')
+local level = tonumber(data[1])
+local last_leak_ns = tonumber(data[2])
+if level == nil then
+	level = 0
+	last_leak_ns = now_ns
+end
+local elapsed_ns = math.max(0, now_ns - last_leak_ns)
+local rate = tonumber(ARGV[2])
+local unit_ns = tonumber(ARGV[3]) * 1000000000
+level = math.max(0, level - (rate * elapsed_ns) / unit_ns)
+local hits = tonumber(ARGV[1])
+local capacity = tonumber(ARGV[4])
+local new_level = level + hits
+local allowed = 0
+if new_level <= capacity then
+	allowed = 1
+	level = new_level
+end
+redis.call('HMSET', KEYS[1], 'level', tostring(level), 'last_leak_ns', now_ns)
+redis.call('EXPIRE', KEYS[1], ARGV[5])
+return {allowed, tostring(level)}
+`
+
+func (this *RateLimitCacheImpl) doLeakyBucket(
+	rl *config.RateLimit, hitsAddend uint32) *pb.RateLimitResponse_DescriptorStatus {
+
+	rate := rl.Limit.RequestsPerUnit
+	unitSeconds := unitToSeconds(rl.Limit.Unit)
+
+	var result []interface{}
+	err := this.client.DoCmd(&result, "EVAL", leakyBucketScript, 1, rl.FullKey,
+		hitsAddend, rate, unitSeconds, rl.Burst, unitSeconds)
+	if err != nil {
+		panic(NewRedisError(err))
+	}
+
+	allowed := toInt64(result[0]) == 1
+	level := parseFloat(result[1])
+
+	code := pb.RateLimitResponse_OK
+	if !allowed {
+		code = pb.RateLimitResponse_OVER_LIMIT
+	}
+
+	return &pb.RateLimitResponse_DescriptorStatus{
+		Code:           code,
+		CurrentLimit:   rl.Limit,
+		LimitRemaining: remaining(rl.Burst, uint32(level)),
+	}
+}
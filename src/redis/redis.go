@@ -0,0 +1,27 @@
+package redis
+
+import "fmt"
+
+// Client abstracts the subset of redis operations the rate limit cache
+// needs, so it can be faked out in tests without a real server and so
+// pipelining/scripting details don't leak into the limiter package.
+type Client interface {
+	DoCmd(response interface{}, cmd string, args ...interface{}) error
+	PipeAppend(cmd string, args ...interface{})
+	PipeDo() ([]interface{}, error)
+	Close() error
+}
+
+// RedisError wraps any failure talking to redis so that callers (the
+// service package's recover()) can distinguish it from a config or logic
+// error and report it separately in stats.
+type RedisError string
+
+func (e RedisError) Error() string {
+	return string(e)
+}
+
+// NewRedisError wraps a lower level error returned by the redis client.
+func NewRedisError(cause error) RedisError {
+	return RedisError(fmt.Sprintf("redis error: %s", cause.Error()))
+}
@@ -0,0 +1,88 @@
+package redis
+
+import (
+	"strconv"
+	"time"
+
+	pb "github.com/envoyproxy/go-control-plane/envoy/service/ratelimit/v3"
+	"github.com/replicon/ratelimit/src/config"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+// tokenBucketScript refills `remaining` by rate*elapsed/unit (capped at
+// burst) before consuming, then atomically persists the new remaining
+// count and refill timestamp. Running refill and consume in one script
+// keeps concurrent replicas from racing each other on the read-modify-write.
+const tokenBucketScript = `
+local now = redis.call('TIME')
+local now_ns = (tonumber(now[1]) * 1000000000) + (tonumber(now[2]) * 1000)
+local data = redis.call('HMGET', KEYS[1], 'remaining', 'last_refill_ns')
+local remaining = tonumber(data[1])
+local last_refill_ns = tonumber(data[2])
+local burst = tonumber(ARGV[4])
+if remaining == nil then
+	remaining = burst
+	last_refill_ns = now_ns
+end
+local elapsed_ns = math.max(0, now_ns - last_refill_ns)
+local rate = tonumber(ARGV[2])
+local unit_ns = tonumber(ARGV[3]) * 1000000000
+remaining = math.min(burst, remaining + (rate * elapsed_ns) / unit_ns)
+local hits = tonumber(ARGV[1])
+local allowed = 0
+local needed = 0
+if remaining - hits >= 0 then
+	allowed = 1
+	remaining = remaining - hits
+else
+	needed = hits - remaining
+end
+redis.call('HMSET', KEYS[1], 'remaining', tostring(remaining), 'last_refill_ns', now_ns)
+redis.call('EXPIRE', KEYS[1], ARGV[5])
+return {allowed, tostring(remaining), tostring(needed)}
+`
+
+func (this *RateLimitCacheImpl) doTokenBucket(
+	rl *config.RateLimit, hitsAddend uint32) *pb.RateLimitResponse_DescriptorStatus {
+
+	rate := rl.Limit.RequestsPerUnit
+	unitSeconds := unitToSeconds(rl.Limit.Unit)
+
+	var result []interface{}
+	err := this.client.DoCmd(&result, "EVAL", tokenBucketScript, 1, rl.FullKey,
+		hitsAddend, rate, unitSeconds, rl.Burst, unitSeconds)
+	if err != nil {
+		panic(NewRedisError(err))
+	}
+
+	allowed := toInt64(result[0]) == 1
+	remainingTokens := parseFloat(result[1])
+	needed := parseFloat(result[2])
+
+	code := pb.RateLimitResponse_OK
+	var durationUntilReset *durationpb.Duration
+	if !allowed {
+		code = pb.RateLimitResponse_OVER_LIMIT
+		resetSeconds := (needed / float64(rate)) * float64(unitSeconds)
+		durationUntilReset = durationpb.New(time.Duration(resetSeconds * float64(time.Second)))
+	}
+
+	return &pb.RateLimitResponse_DescriptorStatus{
+		Code:               code,
+		CurrentLimit:       rl.Limit,
+		LimitRemaining:     uint32(remainingTokens),
+		DurationUntilReset: durationUntilReset,
+	}
+}
+
+func parseFloat(v interface{}) float64 {
+	s, ok := v.(string)
+	if !ok {
+		return 0
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0
+	}
+	return f
+}
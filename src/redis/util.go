@@ -0,0 +1,39 @@
+package redis
+
+import pb "github.com/envoyproxy/go-control-plane/envoy/service/ratelimit/v3"
+
+func unitToSeconds(unit pb.RateLimitResponse_RateLimit_Unit) int64 {
+	switch unit {
+	case pb.RateLimitResponse_RateLimit_SECOND:
+		return 1
+	case pb.RateLimitResponse_RateLimit_MINUTE:
+		return 60
+	case pb.RateLimitResponse_RateLimit_HOUR:
+		return 60 * 60
+	case pb.RateLimitResponse_RateLimit_DAY:
+		return 60 * 60 * 24
+	default:
+		return 1
+	}
+}
+
+func remaining(limit uint32, current uint32) uint32 {
+	if current > limit {
+		return 0
+	}
+	return limit - current
+}
+
+// toInt64 normalizes the handful of types a redis client may hand back
+// for an integer reply (int64 from a real client, float64 from a JSON
+// based mock) into a plain int64.
+func toInt64(v interface{}) int64 {
+	switch t := v.(type) {
+	case int64:
+		return t
+	case float64:
+		return int64(t)
+	default:
+		return 0
+	}
+}
@@ -1,6 +1,10 @@
 package ratelimit
 
 import (
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"sort"
 	"strings"
 	"sync"
 	"time"
@@ -35,6 +39,7 @@ func newShouldRateLimitStats(scope stats.Scope) shouldRateLimitStats {
 type serviceStats struct {
 	configLoadSuccess stats.Counter
 	configLoadError   stats.Counter
+	configLoadNoop    stats.Counter
 	shouldRateLimit   shouldRateLimitStats
 }
 
@@ -42,6 +47,7 @@ func newServiceStats(scope stats.Scope) serviceStats {
 	ret := serviceStats{}
 	ret.configLoadSuccess = scope.NewCounter("config_load_success")
 	ret.configLoadError = scope.NewCounter("config_load_error")
+	ret.configLoadNoop = scope.NewCounter("config_load_noop")
 	ret.shouldRateLimit = newShouldRateLimitStats(scope.Scope("call.should_rate_limit"))
 	return ret
 }
@@ -57,6 +63,7 @@ type service struct {
 	configLock         sync.RWMutex
 	configLoader       config.RateLimitConfigLoader
 	config             config.RateLimitConfig
+	configHash         [32]byte
 	runtimeUpdateEvent chan int
 	cache              limiter.RateLimitCache
 	stats              serviceStats
@@ -64,8 +71,13 @@ type service struct {
 	legacy             *legacyService
 	shadowMode         bool
 	runtimeWatchRoot   bool
+	rateLimitedLogger  *util.RateLimitedLogger
 }
 
+// reloadConfig is used by the background runtime-update loop: it
+// recovers a RateLimitConfigError and logs it, but lets any other panic
+// (a programming error, not a config problem) propagate and crash the
+// process as before.
 func (this *service) reloadConfig() {
 	defer func() {
 		if e := recover(); e != nil {
@@ -76,10 +88,37 @@ func (this *service) reloadConfig() {
 
 			this.stats.configLoadError.Inc()
 			metrics.RateLimitErrors.WithLabelValues("config_reload").Inc()
-			logger.Errorf("error loading new configuration from runtime: %s", configError.Error())
+			this.rateLimitedLogger.Errorf("config_reload_error",
+				"error loading new configuration from runtime: %s", configError.Error())
 		}
 	}()
 
+	this.reloadConfigImpl()
+}
+
+// reloadConfigInitial is used for the synchronous first load in
+// NewService. Unlike reloadConfig, it converts any panic into a returned
+// error instead of crashing the process, so a misconfigured server fails
+// startup cleanly rather than via a crash loop.
+func (this *service) reloadConfigInitial() (err error) {
+	defer func() {
+		if e := recover(); e != nil {
+			this.stats.configLoadError.Inc()
+			metrics.RateLimitErrors.WithLabelValues("config_reload").Inc()
+			if configError, ok := e.(config.RateLimitConfigError); ok {
+				err = configError
+				return
+			}
+
+			err = fmt.Errorf("panic while loading initial configuration: %v", e)
+		}
+	}()
+
+	this.reloadConfigImpl()
+	return nil
+}
+
+func (this *service) reloadConfigImpl() {
 	files := []config.RateLimitConfigToLoad{}
 	snapshot := this.runtime.Snapshot()
 	for _, key := range snapshot.Keys() {
@@ -90,12 +129,41 @@ func (this *service) reloadConfig() {
 		files = append(files, config.RateLimitConfigToLoad{key, snapshot.Get(key)})
 	}
 
+	hash := hashConfigFiles(files)
+	if hash == this.configHash {
+		this.stats.configLoadNoop.Inc()
+		this.rateLimitedLogger.Debugf("config_reload_noop", "snapshot contents unchanged since last reload, skipping config rebuild")
+		return
+	}
+
 	newConfig := this.configLoader.Load(files, this.rlStatsScope)
 	this.stats.configLoadSuccess.Inc()
 	this.configLock.Lock()
 	this.config = newConfig
+	this.configHash = hash
 	this.configLock.Unlock()
+}
+
+// hashConfigFiles computes a stable hash over the sorted {key, value}
+// pairs of a runtime snapshot, so reloadConfig can tell a byte-identical
+// snapshot apart from one that actually changed without doing a full
+// config parse and compare.
+func hashConfigFiles(files []config.RateLimitConfigToLoad) [32]byte {
+	sorted := make([]config.RateLimitConfigToLoad, len(files))
+	copy(sorted, files)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Name < sorted[j].Name })
+
+	h := sha256.New()
+	for _, f := range sorted {
+		io.WriteString(h, f.Name)
+		h.Write([]byte{0})
+		io.WriteString(h, f.FileBytes)
+		h.Write([]byte{0})
+	}
 
+	var sum [32]byte
+	copy(sum[:], h.Sum(nil))
+	return sum
 }
 
 type serviceError string
@@ -110,6 +178,12 @@ func checkServiceErr(something bool, msg string) {
 	}
 }
 
+// shouldRateLimitWorker resolves and checks limits for every descriptor in
+// request. For descriptors configured with a concurrency_limit, a
+// descriptor carrying limiter.ConcurrencyReleaseKey releases a
+// previously acquired slot instead of taking a new one; that distinction
+// is handled entirely within cache.DoLimit so this layer stays agnostic
+// to which dimensions a given limit enforces.
 func (this *service) shouldRateLimitWorker(
 	ctx context.Context, request *pb.RateLimitRequest) *pb.RateLimitResponse {
 
@@ -125,7 +199,8 @@ func (this *service) shouldRateLimitWorker(
 	}
 
 	responseDescriptorStatuses := this.cache.DoLimit(ctx, request, limitsToCheck)
-	assert.Assert(len(limitsToCheck) == len(responseDescriptorStatuses))
+	assert.AssertOrPanicf(len(limitsToCheck) == len(responseDescriptorStatuses),
+		"cache.DoLimit returned %d statuses for %d limits", len(responseDescriptorStatuses), len(limitsToCheck))
 
 	response := &pb.RateLimitResponse{}
 	response.Statuses = make([]*pb.RateLimitResponse_DescriptorStatus, len(request.Descriptors))
@@ -156,19 +231,21 @@ func (this *service) ShouldRateLimit(
 			return
 		}
 
-		logger.Debugf("caught error during call")
+		this.rateLimitedLogger.Debugf("should_rate_limit_recover", "caught error during call")
 		finalResponse = nil
 		switch t := err.(type) {
 		case redis.RedisError:
 			{
 				this.stats.shouldRateLimit.redisError.Inc()
 				metrics.RateLimitErrors.WithLabelValues("redis").Inc()
+				this.rateLimitedLogger.Warnf("redis_error", "redis error handling ShouldRateLimit: %s", t.Error())
 				finalError = t
 			}
 		case serviceError:
 			{
 				this.stats.shouldRateLimit.serviceError.Inc()
 				metrics.RateLimitErrors.WithLabelValues("service").Inc()
+				this.rateLimitedLogger.Warnf("service_error", "service error handling ShouldRateLimit: %s", t.Error())
 				finalError = t
 			}
 		default:
@@ -209,7 +286,7 @@ func (this *service) GetCurrentConfig() config.RateLimitConfig {
 }
 
 func NewService(runtime loader.IFace, cache limiter.RateLimitCache,
-	configLoader config.RateLimitConfigLoader, stats stats.Scope, shadowMode bool, runtimeWatchRoot bool) RateLimitServiceServer {
+	configLoader config.RateLimitConfigLoader, stats stats.Scope, shadowMode bool, runtimeWatchRoot bool) (RateLimitServiceServer, error) {
 
 	newService := &service{
 		runtime:            runtime,
@@ -222,6 +299,7 @@ func NewService(runtime loader.IFace, cache limiter.RateLimitCache,
 		rlStatsScope:       stats.Scope("rate_limit"),
 		shadowMode:         shadowMode,
 		runtimeWatchRoot:   runtimeWatchRoot,
+		rateLimitedLogger:  util.NewRateLimitedLogger(time.Second),
 	}
 	newService.legacy = &legacyService{
 		s:                          newService,
@@ -230,16 +308,19 @@ func NewService(runtime loader.IFace, cache limiter.RateLimitCache,
 
 	runtime.AddUpdateCallback(newService.runtimeUpdateEvent)
 
-	newService.reloadConfig()
+	if err := newService.reloadConfigInitial(); err != nil {
+		return nil, err
+	}
+
 	go func() {
 		// No exit right now.
 		for {
-			logger.Debugf("waiting for runtime update")
+			newService.rateLimitedLogger.Debugf("waiting_for_runtime_update", "waiting for runtime update")
 			<-newService.runtimeUpdateEvent
-			logger.Debugf("got runtime update and reloading config")
+			newService.rateLimitedLogger.Debugf("got_runtime_update", "got runtime update and reloading config")
 			newService.reloadConfig()
 		}
 	}()
 
-	return newService
+	return newService, nil
 }
@@ -0,0 +1,31 @@
+package ratelimit
+
+import (
+	"testing"
+
+	"github.com/replicon/ratelimit/src/config"
+)
+
+func TestHashConfigFiles_OrderIndependent(t *testing.T) {
+	a := []config.RateLimitConfigToLoad{
+		{Name: "one.yaml", FileBytes: "a: 1"},
+		{Name: "two.yaml", FileBytes: "b: 2"},
+	}
+	b := []config.RateLimitConfigToLoad{
+		{Name: "two.yaml", FileBytes: "b: 2"},
+		{Name: "one.yaml", FileBytes: "a: 1"},
+	}
+
+	if hashConfigFiles(a) != hashConfigFiles(b) {
+		t.Fatalf("expected differently-ordered but equal file sets to hash identically")
+	}
+}
+
+func TestHashConfigFiles_DiffersOnContentChange(t *testing.T) {
+	a := []config.RateLimitConfigToLoad{{Name: "one.yaml", FileBytes: "a: 1"}}
+	b := []config.RateLimitConfigToLoad{{Name: "one.yaml", FileBytes: "a: 2"}}
+
+	if hashConfigFiles(a) == hashConfigFiles(b) {
+		t.Fatalf("expected changed file contents to produce a different hash")
+	}
+}
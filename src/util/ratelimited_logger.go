@@ -0,0 +1,80 @@
+package util
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	logger "github.com/sirupsen/logrus"
+)
+
+// RateLimitedLogger wraps logrus so a single noisy message key (e.g. a
+// repeating redis timeout) logs at most once per interval instead of
+// once per call in the hot path, which would itself become a load
+// amplifier when a backend is degraded. Calls suppressed between
+// emissions are counted and folded into the next one rather than
+// silently dropped.
+type RateLimitedLogger struct {
+	interval time.Duration
+
+	mu         sync.Mutex
+	lastEmit   map[string]time.Time
+	suppressed map[string]int
+}
+
+// NewRateLimitedLogger returns a RateLimitedLogger that emits a given key
+// at most once per interval. interval <= 0 defaults to one second.
+func NewRateLimitedLogger(interval time.Duration) *RateLimitedLogger {
+	if interval <= 0 {
+		interval = time.Second
+	}
+
+	return &RateLimitedLogger{
+		interval:   interval,
+		lastEmit:   map[string]time.Time{},
+		suppressed: map[string]int{},
+	}
+}
+
+func (l *RateLimitedLogger) Errorf(key string, format string, args ...interface{}) {
+	l.log(key, logger.Errorf, format, args...)
+}
+
+func (l *RateLimitedLogger) Warnf(key string, format string, args ...interface{}) {
+	l.log(key, logger.Warnf, format, args...)
+}
+
+func (l *RateLimitedLogger) Debugf(key string, format string, args ...interface{}) {
+	l.log(key, logger.Debugf, format, args...)
+}
+
+func (l *RateLimitedLogger) log(key string, emit func(string, ...interface{}), format string, args ...interface{}) {
+	suppressed, ok := l.reserve(key)
+	if !ok {
+		return
+	}
+
+	msg := fmt.Sprintf(format, args...)
+	if suppressed > 0 {
+		msg = fmt.Sprintf("%s (repeated %d times in %s)", msg, suppressed, l.interval)
+	}
+	emit("%s", msg)
+}
+
+// reserve reports whether key may emit now, and the number of calls
+// suppressed since its last emission.
+func (l *RateLimitedLogger) reserve(key string) (suppressedSinceLastEmit int, ok bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	if last, seen := l.lastEmit[key]; seen && now.Sub(last) < l.interval {
+		l.suppressed[key]++
+		return 0, false
+	}
+
+	suppressedSinceLastEmit = l.suppressed[key]
+	l.suppressed[key] = 0
+	l.lastEmit[key] = now
+	return suppressedSinceLastEmit, true
+}
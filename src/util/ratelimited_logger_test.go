@@ -0,0 +1,34 @@
+package util
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRateLimitedLogger_SuppressesWithinInterval(t *testing.T) {
+	l := NewRateLimitedLogger(time.Hour)
+
+	suppressed, ok := l.reserve("k")
+	if !ok || suppressed != 0 {
+		t.Fatalf("expected first call to emit with 0 suppressed, got ok=%v suppressed=%d", ok, suppressed)
+	}
+
+	if _, ok := l.reserve("k"); ok {
+		t.Fatalf("expected call within interval to be suppressed")
+	}
+	if _, ok := l.reserve("k"); ok {
+		t.Fatalf("expected second call within interval to be suppressed")
+	}
+
+	l.mu.Lock()
+	l.lastEmit["k"] = time.Now().Add(-2 * time.Hour)
+	l.mu.Unlock()
+
+	suppressed, ok = l.reserve("k")
+	if !ok {
+		t.Fatalf("expected call after interval to emit")
+	}
+	if suppressed != 2 {
+		t.Fatalf("expected 2 suppressed calls folded into next emission, got %d", suppressed)
+	}
+}
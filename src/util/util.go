@@ -0,0 +1,34 @@
+package util
+
+import (
+	pb "github.com/envoyproxy/go-control-plane/envoy/service/ratelimit/v3"
+)
+
+// MetricsDescriptor is the flattened, label-friendly form of a descriptor
+// status used when reporting per-descriptor metrics.
+type MetricsDescriptor struct {
+	Key   string
+	Value string
+	Limit string
+	Unit  string
+}
+
+// ConvertToMetricsDescriptor pulls the last key/value pair off of the
+// request descriptor (the one the limit actually matched against) and
+// pairs it with the limit the descriptor status was checked against, so
+// callers can label metrics without re-deriving the match.
+func ConvertToMetricsDescriptor(status *pb.RateLimitResponse_DescriptorStatus, descriptor *pb.RateLimitDescriptor) MetricsDescriptor {
+	ret := MetricsDescriptor{}
+	if entries := descriptor.GetEntries(); len(entries) > 0 {
+		last := entries[len(entries)-1]
+		ret.Key = last.GetKey()
+		ret.Value = last.GetValue()
+	}
+
+	if limit := status.GetCurrentLimit(); limit != nil {
+		ret.Limit = limit.String()
+		ret.Unit = limit.GetUnit().String()
+	}
+
+	return ret
+}
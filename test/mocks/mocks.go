@@ -4,5 +4,5 @@ package mocks
 //go:generate go run github.com/golang/mock/mockgen -destination ./runtime/loader/loader.go github.com/lyft/goruntime/loader IFace
 //go:generate go run github.com/golang/mock/mockgen -destination ./config/config.go github.com/replicon/ratelimit/src/config RateLimitConfig,RateLimitConfigLoader
 //go:generate go run github.com/golang/mock/mockgen -destination ./redis/redis.go github.com/replicon/ratelimit/src/redis Client
-//go:generate go run github.com/golang/mock/mockgen -destination ./limiter/limiter.go github.com/replicon/ratelimit/src/limiter RateLimitCache,TimeSource,JitterRandSource
+//go:generate go run github.com/golang/mock/mockgen -destination ./limiter/limiter.go github.com/replicon/ratelimit/src/limiter RateLimitCache
 //go:generate go run github.com/golang/mock/mockgen -destination ./rls/rls.go github.com/envoyproxy/go-control-plane/envoy/service/ratelimit/v3 RateLimitServiceServer